@@ -0,0 +1,95 @@
+#!/bin/bash
+
+# fail if trying to reference a variable that is not set.
+set -u
+set -e
+
+# Usage: bump_extension_version.sh <new_version>
+# e.g.   bump_extension_version.sh 0.103-0
+newVersion=${1:-""}
+
+if [[ ! "$newVersion" =~ ^[0-9]+\.[0-9]+-[0-9]+$ ]]; then
+    echo "Usage: $0 <new_version> (of the form major.minor-patch, e.g. 0.103-0)"
+    exit 1
+fi
+
+source="${BASH_SOURCE[0]}"
+while [[ -h $source ]]; do
+   scriptroot="$( cd -P "$( dirname "$source" )" && pwd )"
+   source="$(readlink "$source")"
+
+   # if $source was a relative symlink, we need to resolve it relative to the path where the
+   # symlink file was located
+   [[ $source != /* ]] && source="$scriptroot/$source"
+done
+
+repoScriptDir="$( cd -P "$( dirname "$source" )" && pwd )"
+repoRootDir="$( cd -P "$repoScriptDir/.." && pwd )"
+
+. $repoScriptDir/utils.sh
+
+changedFiles=""
+changelogFile="$repoRootDir/CHANGELOG.md"
+extensions="pg_documentdb_core:documentdb_core pg_documentdb:documentdb internal/pg_documentdb_distributed:documentdb_distributed"
+
+function CheckCanBumpExtensionVersion()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    local _control_file="$repoRootDir/$_extension_dir/$_extension_name.control"
+    local _old_version
+    _old_version=$(GetControlFileDefaultVersion $_control_file)
+
+    # Ensure we only move forward: the new version must sort after the old one.
+    local _highest_version=$(printf "%s\n%s\n" "$_old_version" "$newVersion" | sort --version-sort | tail --lines 1)
+    if [ "$_highest_version" != "$newVersion" ]; then
+        echo "New version $newVersion is older than current version $_old_version in $_control_file"
+        exit 1
+    fi
+}
+
+function BumpExtensionVersion()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    local _control_file="$repoRootDir/$_extension_dir/$_extension_name.control"
+    local _old_version
+    _old_version=$(GetControlFileDefaultVersion $_control_file)
+
+    if [ "$_old_version" == "$newVersion" ]; then
+        echo "$_control_file is already at version $newVersion"
+        return
+    fi
+
+    echo "Bumping $_extension_name from $_old_version to $newVersion"
+    sed -i "s/^default_version = '.*'$/default_version = '$newVersion'/" $_control_file
+    changedFiles="$changedFiles $_extension_dir/$_extension_name.control"
+
+    local _upgrade_script="$_extension_dir/sql/$_extension_name--$_old_version--$newVersion.sql"
+    if [ ! -f "$repoRootDir/$_upgrade_script" ]; then
+        touch "$repoRootDir/$_upgrade_script"
+        changedFiles="$changedFiles $_upgrade_script"
+    fi
+}
+
+# Check every control file before editing any, so a failure can't leave a half-bumped tree.
+for extension in $extensions; do
+    CheckCanBumpExtensionVersion ${extension%%:*} ${extension##*:}
+done
+
+for extension in $extensions; do
+    BumpExtensionVersion ${extension%%:*} ${extension##*:}
+done
+
+# Start a changelog section for the new version if there isn't one yet.
+if ! grep --quiet --fixed-strings "### documentdb v$newVersion " $changelogFile; then
+    sed -i "1i ### documentdb v$newVersion (Unreleased) ###\n" $changelogFile
+    changedFiles="$changedFiles CHANGELOG.md"
+fi
+
+echo "Changed files:"
+for f in $changedFiles; do
+    echo "  $f"
+done
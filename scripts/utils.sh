@@ -48,6 +48,19 @@ function GetPostgresSourceRef()
   echo $POSTGRESQL_REF
 }
 
+function GetControlFileDefaultVersion()
+{
+  local controlFile=$1
+  local defaultVersion=$(sed -n "s/^default_version = '\(.*\)'$/\1/p" $controlFile)
+
+  if [ "$defaultVersion" == "" ]; then
+    echo "Unable to read default_version from $controlFile" >&2
+    exit 1
+  fi
+
+  echo $defaultVersion
+}
+
 function GetPGCTL()
 {
   local pgVersion=${PG_VERSION:-16}
@@ -14,6 +14,13 @@ done
 
 repoScriptDir="$( cd -P "$( dirname "$source" )" && pwd )"
 
+# Capture the versions CI builds with before setup_versions.sh overwrites CITUS_VERSION.
+requestedPgVersion=${PG_VERSION:-16}
+requestedCitusVersion=${CITUS_VERSION:-12}
+
+. $repoScriptDir/utils.sh
+. $repoScriptDir/setup_versions.sh
+
 function ValidateNoCitusReferences()
 {
     local _dir_to_check=$1
@@ -72,6 +79,307 @@ function CheckSqlLatestVersionMatchesLatestFile()
     done
 }
 
+function GetUpgradeScriptEdges()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    # Prints one "<from> <to>" line per <extension>--<from>--<to>.sql upgrade script.
+    find "$repoScriptDir/../$_extension_dir/sql" -maxdepth 1 -iname "$_extension_name--*--*.sql" -exec basename {} .sql \; \
+        | sed -E "s/^$_extension_name--(.+)--(.+)$/\1 \2/" \
+        | sort --version-sort
+}
+
+function GetShippedVersions()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    # Prints every version we have ever shipped an install or upgrade script for.
+    local _install_versions=$(find "$repoScriptDir/../$_extension_dir/sql" -maxdepth 1 -iname "$_extension_name--*.sql" -exec basename {} .sql \; \
+        | { grep --invert-match -E -e "--.+--" || true; } \
+        | sed -E "s/^$_extension_name--(.+)$/\1/")
+    local _edges=$(GetUpgradeScriptEdges $_extension_dir $_extension_name)
+
+    echo "$_install_versions $_edges" | tr ' ' '\n' | { grep --invert-match '^$' || true; } | sort --version-sort --unique
+}
+
+function CheckUpgradeScriptsExist()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    local _control_file="$repoScriptDir/../$_extension_dir/$_extension_name.control"
+    local _default_version
+    _default_version=$(GetControlFileDefaultVersion $_control_file)
+
+    local _edges=$(GetUpgradeScriptEdges $_extension_dir $_extension_name)
+    local _all_versions=$(GetShippedVersions $_extension_dir $_extension_name)
+
+    # Walk the upgrade edges backwards from default_version to find every version
+    # that can reach it via ALTER EXTENSION UPDATE.
+    local _reachable=" $_default_version "
+    local _changed="true"
+    while [ "$_changed" == "true" ]; do
+        _changed="false"
+        while read -r _from _to; do
+            if [ "$_from" == "" ]; then
+                continue;
+            fi;
+            if [[ $_reachable =~ " $_to " ]] && [[ ! $_reachable =~ " $_from " ]]; then
+                _reachable="$_reachable$_from "
+                _changed="true"
+            fi
+        done <<< "$_edges"
+    done
+
+    # Versions in upgrade order, including default_version even if nothing creates it yet.
+    local _ordered_versions=$( { echo "$_all_versions"; echo $_default_version; } | { grep --invert-match '^$' || true; } | sort --version-sort --unique)
+
+    local _missing=""
+    local _previous_version=""
+    for _version in $_ordered_versions; do
+        if [ "$_previous_version" != "" ] && [[ ! $_reachable =~ " $_previous_version " ]]; then
+            # A version with an upgrade script is only broken further along the chain, which is
+            # reported on its own; a version without one needs a script to the next shipped version.
+            if [[ $'\n'"$_edges" == *$'\n'"$_previous_version "* ]]; then
+                echo "No upgrade path for $_extension_name from $_previous_version to $_default_version"
+            else
+                echo "No upgrade path for $_extension_name from $_previous_version to $_default_version: missing $_extension_dir/sql/$_extension_name--$_previous_version--$_version.sql"
+            fi
+            _missing="true"
+        fi
+        _previous_version=$_version
+    done
+
+    # A shipped version newer than default_version has no next version to point at.
+    if [ "$_previous_version" != "" ] && [[ ! $_reachable =~ " $_previous_version " ]]; then
+        echo "No upgrade path for $_extension_name from $_previous_version to $_default_version"
+        _missing="true"
+    fi
+
+    # With no shipped versions at all, default_version needs an install script.
+    if [ "$_all_versions" == "" ]; then
+        echo "No install script for $_extension_name: missing $_extension_dir/sql/$_extension_name--$_default_version.sql"
+        _missing="true"
+    fi
+
+    if [ "$_missing" != "" ]; then
+        echo "Found missing upgrade scripts for $_extension_name"
+        exit 1
+    fi
+
+    echo "All $_extension_name versions can be upgraded to $_default_version"
+}
+
+function CheckControlFileMatchesCore()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    local _core_control_file="$repoScriptDir/../pg_documentdb_core/documentdb_core.control"
+    local _control_file="$repoScriptDir/../$_extension_dir/$_extension_name.control"
+
+    local _core_version
+    _core_version=$(GetControlFileDefaultVersion $_core_control_file)
+    local _version
+    _version=$(GetControlFileDefaultVersion $_control_file)
+
+    if [ "$_version" != "$_core_version" ]; then
+        echo "$_extension_name default_version '$_version' does not match documentdb_core default_version '$_core_version'"
+        exit 1
+    fi
+
+    echo "$_extension_name default_version matches documentdb_core $_core_version"
+}
+
+function CheckControlFilePolicy()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+    local _expected_requires=$3
+
+    local _control_file="$repoScriptDir/../$_extension_dir/$_extension_name.control"
+
+    local _version
+    _version=$(GetControlFileDefaultVersion $_control_file)
+    local _requires=$(sed -n "s/^requires = '\(.*\)'$/\1/p" $_control_file)
+    local _module_pathname=$(sed -n "s/^module_pathname = '\(.*\)'$/\1/p" $_control_file)
+    local _relocatable=$(sed -n "s/^relocatable = \(.*\)$/\1/p" $_control_file)
+    local _superuser=$(sed -n "s/^superuser = \(.*\)$/\1/p" $_control_file)
+
+    local _foundInvalid=""
+    if [[ ! $_version =~ ^[0-9]+\.[0-9]+-[0-9]+$ ]]; then
+        echo "$_extension_name default_version '$_version' must be of the form major.minor-patch"
+        _foundInvalid="true"
+    fi
+
+    # The order matters: CREATE EXTENSION ... CASCADE installs dependencies in this order.
+    if [ "$_requires" != "$_expected_requires" ]; then
+        echo "$_extension_name requires '$_requires' but is expected to require '$_expected_requires'"
+        _foundInvalid="true"
+    fi
+
+    if [ "$_module_pathname" != "\$libdir/pg_$_extension_name" ]; then
+        echo "$_extension_name module_pathname '$_module_pathname' must be '\$libdir/pg_$_extension_name'"
+        _foundInvalid="true"
+    fi
+
+    if [ "$_relocatable" != "false" ]; then
+        echo "$_extension_name must not be relocatable (found relocatable = $_relocatable)"
+        _foundInvalid="true"
+    fi
+
+    if [ "$_superuser" != "true" ]; then
+        echo "$_extension_name must require superuser (found superuser = $_superuser)"
+        _foundInvalid="true"
+    fi
+
+    if [ "$_foundInvalid" != "" ]; then
+        echo "Found invalid control file $_control_file"
+        exit 1
+    fi
+
+    echo "$_extension_name control file matches policy"
+}
+
+function CheckRequiredExtensionsArePinned()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    local _control_file="$repoScriptDir/../$_extension_dir/$_extension_name.control"
+    local _workflow_file="$repoScriptDir/../.github/workflows/regress_tests.yml"
+    local _requires=$(sed -n "s/^requires = '\(.*\)'$/\1/p" $_control_file)
+
+    local _foundInvalid=""
+    echo "Dependencies of $_extension_name for PG $requestedPgVersion:"
+    for _required in ${_requires//,/ }; do
+        local _install_script="install_setup_$_required.sh"
+        local _version_command=""
+        case $_required in
+            documentdb_core|documentdb)
+                echo "  $_required: (this repository)"
+                continue ;;
+            citus) _install_script="install_setup_citus_core_oss.sh"; _version_command="GetCitusVersion $requestedCitusVersion" ;;
+            pg_cron) _version_command="GetPgCronVersion" ;;
+            vector) _install_script="install_setup_pgvector.sh"; _version_command="GetPgVectorVersion" ;;
+            postgis) _version_command="GetPostgisVersion" ;;
+            rum) _install_script="install_setup_rum_oss.sh"; _version_command="GetRumVersion" ;;
+            # tsm_system_rows is built from the PostgreSQL contrib sources
+            tsm_system_rows) _install_script="install_setup_system_rows.sh"; _version_command="GetPostgresSourceRef $requestedPgVersion" ;;
+        esac
+
+        if [ ! -f "$repoScriptDir/$_install_script" ]; then
+            echo "  $_required: scripts/$_install_script does not exist"
+            _foundInvalid="true"
+            continue
+        fi
+
+        if ! grep --quiet --fixed-strings "/$_install_script" $_workflow_file; then
+            echo "  $_required: scripts/$_install_script is not run by .github/workflows/regress_tests.yml"
+            _foundInvalid="true"
+            continue
+        fi
+
+        local _pinned_version=""
+        if [ "$_version_command" == "" ] || ! _pinned_version=$($_version_command); then
+            echo "  $_required: no version pinned in scripts/setup_versions.sh${_pinned_version:+ ($_pinned_version)}"
+            _foundInvalid="true"
+            continue
+        fi
+
+        echo "  $_required: $_pinned_version (scripts/$_install_script)"
+    done
+
+    if [ "$_foundInvalid" != "" ]; then
+        echo "Found required extensions of $_extension_name missing from the build dependencies"
+        exit 1
+    fi
+}
+
+function CheckChangelogHasVersion()
+{
+    local _control_file="$repoScriptDir/../pg_documentdb/documentdb.control"
+    local _changelog_file="$repoScriptDir/../CHANGELOG.md"
+    local _version
+    _version=$(GetControlFileDefaultVersion $_control_file)
+
+    # Sections are of the form "### documentdb v0.102-0 (Unreleased) ###"
+    if ! grep --quiet --fixed-strings "### documentdb v$_version " $_changelog_file; then
+        echo "CHANGELOG.md has no '### documentdb v$_version' section for default_version $_version"
+        exit 1
+    fi
+
+    # While the section is still "(Unreleased)" entries are added as changes land; once it is
+    # dated for the release, it must describe what changed.
+    if grep --quiet --fixed-strings "### documentdb v$_version (Unreleased) ###" $_changelog_file; then
+        echo "CHANGELOG.md has an unreleased section for $_version"
+        return
+    fi
+
+    # Count the "* " entries between this version's heading and the next "###" heading.
+    local _entries=$(awk -v heading="### documentdb v$_version " '
+        index($0, heading) == 1 { inSection = 1; next }
+        /^###/ { inSection = 0 }
+        inSection && /^\* / { count++ }
+        END { print count + 0 }' $_changelog_file)
+
+    if [ "$_entries" == "0" ]; then
+        echo "CHANGELOG.md section for $_version is dated but has no '* ' entries; describe the changes before tagging"
+        exit 1
+    fi
+
+    echo "CHANGELOG.md has $_entries entries for released $_version"
+}
+
+function WriteUpgradePathGraph()
+{
+    local _extension_dir=$1
+    local _extension_name=$2
+
+    # Only meaningful when running as a GitHub Actions step.
+    if [ "${GITHUB_STEP_SUMMARY:-""}" == "" ]; then
+        return
+    fi
+
+    local _control_file="$repoScriptDir/../$_extension_dir/$_extension_name.control"
+    local _default_version
+    _default_version=$(GetControlFileDefaultVersion $_control_file)
+
+    # Include versions with no upgrade edges so disconnected versions show up as lone nodes.
+    local _edges=$(GetUpgradeScriptEdges $_extension_dir $_extension_name)
+    local _all_versions=$( { GetShippedVersions $_extension_dir $_extension_name; echo $_default_version; } | sort --version-sort --unique)
+
+    {
+        echo "### $_extension_name upgrade paths"
+        echo ""
+        echo '```mermaid'
+        echo "graph LR"
+        # Mermaid node ids can't contain '.' or '-', so use v0_102_0["0.102-0"] style nodes.
+        for _version in $_all_versions; do
+            local _label=$_version
+            if [ "$_version" == "$_default_version" ]; then
+                _label="$_version (default_version)"
+            fi
+            echo "    v${_version//[.-]/_}[\"$_label\"]"
+        done
+        while read -r _from _to; do
+            if [ "$_from" == "" ]; then
+                continue;
+            fi;
+            echo "    v${_from//[.-]/_} --> v${_to//[.-]/_}"
+        done <<< "$_edges"
+        echo '```'
+        echo ""
+    } >> $GITHUB_STEP_SUMMARY
+}
+
+# Render the upgrade paths in the workflow step summary first, so reviewers see them even when a check below fails
+WriteUpgradePathGraph pg_documentdb_core documentdb_core
+WriteUpgradePathGraph pg_documentdb documentdb
+WriteUpgradePathGraph internal/pg_documentdb_distributed documentdb_distributed
+
 # Ensure that citus references are done via hooks and not in the SQL/C files
 ValidateNoCitusReferences $repoScriptDir/../pg_documentdb_core
 ValidateNoCitusReferences $repoScriptDir/../pg_documentdb
@@ -81,5 +389,26 @@ CheckSqlLatestVersionMatchesLatestFile pg_documentdb_core
 CheckSqlLatestVersionMatchesLatestFile pg_documentdb
 CheckSqlLatestVersionMatchesLatestFile internal/pg_documentdb_distributed
 
+# Ensure every shipped version has an upgrade path to the current default_version
+CheckUpgradeScriptsExist pg_documentdb_core documentdb_core
+CheckUpgradeScriptsExist pg_documentdb documentdb
+CheckUpgradeScriptsExist internal/pg_documentdb_distributed documentdb_distributed
+
+# Ensure the extensions are versioned together
+CheckControlFileMatchesCore pg_documentdb documentdb
+CheckControlFileMatchesCore internal/pg_documentdb_distributed documentdb_distributed
+
+# Ensure control file edits keep the expected dependencies and settings
+CheckControlFilePolicy pg_documentdb_core documentdb_core ""
+CheckControlFilePolicy pg_documentdb documentdb "documentdb_core, pg_cron, tsm_system_rows, vector, postgis, rum"
+CheckControlFilePolicy internal/pg_documentdb_distributed documentdb_distributed "citus, documentdb_core, documentdb"
+
+# Ensure every required extension is one we pin and install for the build
+CheckRequiredExtensionsArePinned pg_documentdb documentdb
+CheckRequiredExtensionsArePinned internal/pg_documentdb_distributed documentdb_distributed
+
+# Ensure the changelog has a section for the current version, with entries once it is dated for release
+CheckChangelogHasVersion
+
 # Ensure no header collisions
 $repoScriptDir/validate_headers.sh
\ No newline at end of file